@@ -0,0 +1,129 @@
+// Package webauthn wires github.com/go-webauthn/webauthn into a small
+// Service that the backend can use to register and verify passkeys
+// alongside the MetaMask signature flow. It only orchestrates the
+// begin/finish ceremonies and where their in-flight session data lives;
+// callers supply their own webauthn.User implementation and persist the
+// resulting credentials.
+package webauthn
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// SessionStore stashes the in-progress ceremony data between a begin and a
+// finish call, keyed by the user handle the ceremony was started for.
+type SessionStore interface {
+	Save(userHandle string, data *webauthn.SessionData) error
+	Load(userHandle string) (*webauthn.SessionData, error)
+	Delete(userHandle string)
+}
+
+type MemSessionStore struct {
+	lock sync.Mutex
+	data map[string]*webauthn.SessionData
+}
+
+func NewMemSessionStore() *MemSessionStore {
+	return &MemSessionStore{data: make(map[string]*webauthn.SessionData)}
+}
+
+func (s *MemSessionStore) Save(userHandle string, data *webauthn.SessionData) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.data[userHandle] = data
+	return nil
+}
+
+func (s *MemSessionStore) Load(userHandle string) (*webauthn.SessionData, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	data, ok := s.data[userHandle]
+	if !ok {
+		return nil, fmt.Errorf("no webauthn session for %q", userHandle)
+	}
+	return data, nil
+}
+
+func (s *MemSessionStore) Delete(userHandle string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.data, userHandle)
+}
+
+// Service drives the WebAuthn registration and login ceremonies.
+type Service struct {
+	wa       *webauthn.WebAuthn
+	sessions SessionStore
+}
+
+func NewService(rpDisplayName, rpID string, rpOrigins []string, sessions SessionStore) (*Service, error) {
+	wa, err := webauthn.New(&webauthn.Config{
+		RPDisplayName: rpDisplayName,
+		RPID:          rpID,
+		RPOrigins:     rpOrigins,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("new webauthn instance: %w", err)
+	}
+	return &Service{wa: wa, sessions: sessions}, nil
+}
+
+func (s *Service) BeginRegistration(user webauthn.User) (*protocol.CredentialCreation, error) {
+	options, session, err := s.wa.BeginRegistration(user)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sessions.Save(string(user.WebAuthnID()), session); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+func (s *Service) FinishRegistration(user webauthn.User, r *http.Request) (*webauthn.Credential, error) {
+	session, err := s.sessions.Load(string(user.WebAuthnID()))
+	if err != nil {
+		return nil, err
+	}
+	defer s.sessions.Delete(string(user.WebAuthnID()))
+	return s.wa.FinishRegistration(user, *session, r)
+}
+
+func (s *Service) BeginLogin(user webauthn.User) (*protocol.CredentialAssertion, error) {
+	options, session, err := s.wa.BeginLogin(user)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sessions.Save(string(user.WebAuthnID()), session); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+func (s *Service) FinishLogin(user webauthn.User, r *http.Request) (*webauthn.Credential, error) {
+	session, err := s.sessions.Load(string(user.WebAuthnID()))
+	if err != nil {
+		return nil, err
+	}
+	defer s.sessions.Delete(string(user.WebAuthnID()))
+	return s.wa.FinishLogin(user, *session, r)
+}
+
+// NewUserHandle returns a random, opaque user handle suitable as a WebAuthn
+// user ID. It mirrors the CSPRNG approach the MetaMask nonce uses: a
+// uniformly random big.Int rendered as a base-10 string.
+func NewUserHandle() (string, error) {
+	max := new(big.Int)
+	max.Exp(big.NewInt(2), big.NewInt(130), nil).Sub(max, big.NewInt(1))
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return n.Text(10), nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/sirupsen/logrus"
+)
+
+// logRequestError logs a single non-2xx response with enough context to
+// debug it after the fact: which address (if known) triggered it, which
+// path, from where, and why.
+func logRequestError(logger *logrus.Logger, r *http.Request, address string, err error) {
+	logger.WithFields(logrus.Fields{
+		"address":   address,
+		"path":      r.URL.Path,
+		"remote_ip": r.RemoteAddr,
+	}).WithError(err).Warn("request failed")
+}
+
+// RequestLogger assigns each request an ID (propagated via X-Request-ID,
+// reusing whatever chi's RequestID middleware put in the context, or a fresh
+// random one when there is none) and logs
+// method, path, status and latency once the request completes.
+func RequestLogger(logger *logrus.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			reqID := middleware.GetReqID(r.Context())
+			if reqID == "" {
+				if id, err := GetNonce(); err == nil {
+					reqID = id
+					r = r.WithContext(context.WithValue(r.Context(), middleware.RequestIDKey, reqID))
+				}
+			}
+			w.Header().Set("X-Request-ID", reqID)
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			logger.WithFields(logrus.Fields{
+				"request_id": reqID,
+				"method":     r.Method,
+				"path":       r.URL.Path,
+				"status":     ww.Status(),
+				"latency":    time.Since(start),
+			}).Info("handled request")
+		})
+	}
+}
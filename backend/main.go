@@ -2,62 +2,36 @@ package main
 
 import (
 	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
 	"math/big"
 	"net/http"
+	"os"
 	"regexp"
+	"runtime/debug"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/go-chi/chi"
+	chimiddleware "github.com/go-chi/chi/middleware"
 	"github.com/go-chi/cors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+
+	authwebauthn "github.com/gosom/blog-metamask-login-tutorial/backend/auth/webauthn"
+	"github.com/gosom/blog-metamask-login-tutorial/backend/siwe"
 )
 
 var (
-	ErrUserNotExists  = errors.New("user does not exist")
-	ErrUserExists     = errors.New("user already exists")
 	ErrInvalidAddress = errors.New("invalid address")
+	ErrInvalidSignin  = errors.New("invalid address or signature")
 )
 
-type User struct {
-	Address string
-	Nonce   string
-}
-
-type MemStorage struct {
-	lock  sync.RWMutex
-	users map[string]User
-}
-
-func (m *MemStorage) CreateIfNotExists(u User) error {
-	m.lock.Lock()
-	defer m.lock.Unlock()
-	if _, exists := m.users[u.Address]; exists {
-		return ErrUserExists
-	}
-	m.users[u.Address] = u
-	return nil
-}
-
-func (m *MemStorage) Get(address string) (User, error) {
-	m.lock.RLock()
-	defer m.lock.RUnlock()
-	u, exists := m.users[address]
-	if !exists {
-		return u, ErrUserNotExists
-	}
-	return u, nil
-}
-
-func NewMemStorage() *MemStorage {
-	ans := MemStorage{
-		users: make(map[string]User),
-	}
-	return &ans
-}
-
 // ============================================================================
 
 var hexRegex *regexp.Regexp = regexp.MustCompile(`^0x[a-fA-F0-9]{40}$`)
@@ -73,19 +47,22 @@ func (p RegisterPayload) Validate() error {
 	return nil
 }
 
-func RegisterHandler(storage *MemStorage) http.HandlerFunc {
+func RegisterHandler(storage Storage, logger *logrus.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var p RegisterPayload
 		if err := bindReqBody(r, &p); err != nil {
+			logRequestError(logger, r, "", err)
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 		if err := p.Validate(); err != nil {
+			logRequestError(logger, r, p.Address, err)
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 		nonce, err := GetNonce()
 		if err != nil {
+			logRequestError(logger, r, p.Address, err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
@@ -94,6 +71,7 @@ func RegisterHandler(storage *MemStorage) http.HandlerFunc {
 			Nonce:   nonce,
 		}
 		if err := storage.CreateIfNotExists(u); err != nil {
+			logRequestError(logger, r, u.Address, err)
 			switch errors.Is(err, ErrUserExists) {
 			case true:
 				w.WriteHeader(http.StatusConflict)
@@ -106,15 +84,33 @@ func RegisterHandler(storage *MemStorage) http.HandlerFunc {
 	}
 }
 
-func UserNonceHandler(storage *MemStorage) http.HandlerFunc {
+// SIWEConfig configures the Sign-In with Ethereum messages this server
+// issues and accepts: its own identity (Domain, URI), the chain it expects
+// wallets to be on, the statement shown to the user, and how long an issued
+// message remains valid for.
+type SIWEConfig struct {
+	Domain     string
+	URI        string
+	ChainID    int
+	Statement  string
+	MessageTTL time.Duration
+}
+
+func (c SIWEConfig) expected(nonce string) siwe.ExpectedParams {
+	return siwe.ExpectedParams{Domain: c.Domain, URI: c.URI, Nonce: nonce}
+}
+
+func UserNonceHandler(storage Storage, cfg SIWEConfig, logger *logrus.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		address := chi.URLParam(r, "address")
 		if !hexRegex.MatchString(address) {
+			logRequestError(logger, r, address, ErrInvalidAddress)
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 		user, err := storage.Get(strings.ToLower(address))
 		if err != nil {
+			logRequestError(logger, r, address, err)
 			switch errors.Is(err, ErrUserNotExists) {
 			case true:
 				w.WriteHeader(http.StatusNotFound)
@@ -123,25 +119,205 @@ func UserNonceHandler(storage *MemStorage) http.HandlerFunc {
 			}
 			return
 		}
+		now := time.Now()
+		expiration := now.Add(cfg.MessageTTL)
+		msg := siwe.Message{
+			Domain:         cfg.Domain,
+			Address:        user.Address,
+			Statement:      cfg.Statement,
+			URI:            cfg.URI,
+			Version:        "1",
+			ChainID:        cfg.ChainID,
+			Nonce:          user.Nonce,
+			IssuedAt:       now,
+			ExpirationTime: &expiration,
+		}
 		resp := struct {
-			Nonce string
+			Message string `json:"message"`
 		}{
-			Nonce: user.Nonce,
+			Message: msg.String(),
 		}
-		renderJson(r, w, http.StatusOK, resp)
+		renderJson(logger, r, w, http.StatusOK, resp)
+	}
+}
+
+type SigninPayload struct {
+	Message   string `json:"message"`
+	Signature string `json:"signature"`
+}
+
+func (p SigninPayload) Validate() error {
+	if p.Message == "" {
+		return ErrInvalidSignin
+	}
+	if p.Signature == "" {
+		return ErrInvalidSignin
+	}
+	return nil
+}
+
+// recoverAddress verifies that sigHex is a valid personal_sign (EIP-191)
+// signature of message and returns the lower-cased address that produced it.
+func recoverAddress(message string, sigHex string) (string, error) {
+	sig, err := decodeSignature(sigHex)
+	if err != nil {
+		return "", err
+	}
+	hash := eip191Hash(message)
+	pub, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return "", err
 	}
+	return strings.ToLower(crypto.PubkeyToAddress(*pub).Hex()), nil
 }
 
-func SigninHandler() http.HandlerFunc {
+// decodeSignature parses a 0x-prefixed 65 byte signature and normalizes its
+// trailing recovery id from the MetaMask convention (27/28) to the one
+// crypto.SigToPub expects (0/1).
+func decodeSignature(sigHex string) ([]byte, error) {
+	sigHex = strings.TrimPrefix(sigHex, "0x")
+	sig := make([]byte, hex.DecodedLen(len(sigHex)))
+	n, err := hex.Decode(sig, []byte(sigHex))
+	if err != nil {
+		return nil, err
+	}
+	sig = sig[:n]
+	if len(sig) != 65 {
+		return nil, ErrInvalidSignin
+	}
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	return sig, nil
+}
+
+// eip191Hash reconstructs the digest of the personal_sign (EIP-191) scheme:
+// keccak256("\x19Ethereum Signed Message:\n" + len(message) + message).
+func eip191Hash(message string) []byte {
+	prefixed := fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)
+	return crypto.Keccak256([]byte(prefixed))
+}
+
+func SigninHandler(storage Storage, jwtSecret []byte, cfg SIWEConfig, logger *logrus.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		var p SigninPayload
+		if err := bindReqBody(r, &p); err != nil {
+			logRequestError(logger, r, "", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := p.Validate(); err != nil {
+			logRequestError(logger, r, "", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		msg, err := siwe.Parse(p.Message)
+		if err != nil || !hexRegex.MatchString(msg.Address) {
+			logRequestError(logger, r, msg.Address, err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		address := strings.ToLower(msg.Address)
+		user, err := storage.Get(address)
+		if err != nil {
+			logRequestError(logger, r, address, err)
+			switch errors.Is(err, ErrUserNotExists) {
+			case true:
+				w.WriteHeader(http.StatusNotFound)
+			default:
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+		recovered, err := recoverAddress(p.Message, p.Signature)
+		if err != nil || recovered != address {
+			logRequestError(logger, r, address, ErrInvalidSignin)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := msg.Validate(cfg.expected(user.Nonce), time.Now()); err != nil {
+			logRequestError(logger, r, address, err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		nonce, err := GetNonce()
+		if err != nil {
+			logRequestError(logger, r, address, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if err := storage.UpdateNonce(address, nonce); err != nil {
+			logRequestError(logger, r, address, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		token, err := issueJWT(address, jwtSecret)
+		if err != nil {
+			logRequestError(logger, r, address, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		resp := struct {
+			Token string `json:"token"`
+		}{
+			Token: token,
+		}
+		renderJson(logger, r, w, http.StatusOK, resp)
 	}
 }
 
-func WelcomeHandler() http.HandlerFunc {
+func WelcomeHandler(jwtSecret []byte, logger *logrus.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		address, err := addressFromRequest(r, jwtSecret)
+		if err != nil {
+			logRequestError(logger, r, "", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		resp := struct {
+			Message string `json:"message"`
+		}{
+			Message: fmt.Sprintf("welcome, %s", address),
+		}
+		renderJson(logger, r, w, http.StatusOK, resp)
 	}
 }
 
+// ============================================================================
+// JWT issuance & verification
+
+const jwtTTL = 15 * time.Minute
+
+func issueJWT(address string, secret []byte) (string, error) {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Subject:   address,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(jwtTTL)),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(secret)
+}
+
+func addressFromRequest(r *http.Request, secret []byte) (string, error) {
+	authHeader := r.Header.Get("Authorization")
+	tokenString, ok := strings.CutPrefix(authHeader, "Bearer ")
+	if !ok || tokenString == "" {
+		return "", ErrInvalidSignin
+	}
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return secret, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return claims.Subject, nil
+}
+
 // ============================================================================
 
 var (
@@ -165,14 +341,16 @@ func bindReqBody(r *http.Request, obj any) error {
 	return json.NewDecoder(r.Body).Decode(obj)
 }
 
-func renderJson(r *http.Request, w http.ResponseWriter, statusCode int, res interface{}) {
+func renderJson(logger *logrus.Logger, r *http.Request, w http.ResponseWriter, statusCode int, res interface{}) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8 ")
 	var body []byte
 	if res != nil {
 		var err error
 		body, err = json.Marshal(res)
-		if err != nil { // TODO handle me better
+		if err != nil {
+			logRequestError(logger, r, "", err)
 			w.WriteHeader(http.StatusInternalServerError)
+			return
 		}
 	}
 	w.WriteHeader(statusCode)
@@ -183,28 +361,133 @@ func renderJson(r *http.Request, w http.ResponseWriter, statusCode int, res inte
 
 // ============================================================================
 
+func jwtSecretFromEnv() []byte {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		secret = "dev-secret-change-me"
+	}
+	return []byte(secret)
+}
+
+// storageFromEnv picks the storage backend via STORAGE_BACKEND=mem|redis
+// (defaulting to mem), configuring the redis backend from REDIS_URL.
+func storageFromEnv() (Storage, error) {
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "mem":
+		return NewMemStorage(), nil
+	case "redis":
+		redisURL := os.Getenv("REDIS_URL")
+		if redisURL == "" {
+			return nil, errors.New("REDIS_URL is required when STORAGE_BACKEND=redis")
+		}
+		return NewRedisStorage(redisURL)
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND: %q", backend)
+	}
+}
+
+// siweConfigFromEnv configures the Sign-In with Ethereum messages from
+// SIWE_DOMAIN, SIWE_URI, SIWE_CHAIN_ID, SIWE_STATEMENT and SIWE_MESSAGE_TTL
+// (a Go duration string, e.g. "5m").
+func siweConfigFromEnv() (SIWEConfig, error) {
+	cfg := SIWEConfig{
+		Domain:     os.Getenv("SIWE_DOMAIN"),
+		URI:        os.Getenv("SIWE_URI"),
+		Statement:  os.Getenv("SIWE_STATEMENT"),
+		ChainID:    1,
+		MessageTTL: 5 * time.Minute,
+	}
+	if cfg.Domain == "" {
+		cfg.Domain = "localhost:8001"
+	}
+	if cfg.URI == "" {
+		cfg.URI = "http://localhost:8001"
+	}
+	if cfg.Statement == "" {
+		cfg.Statement = "Sign in with your Ethereum account."
+	}
+	if v := os.Getenv("SIWE_CHAIN_ID"); v != "" {
+		chainID, err := strconv.Atoi(v)
+		if err != nil {
+			return SIWEConfig{}, fmt.Errorf("invalid SIWE_CHAIN_ID: %w", err)
+		}
+		cfg.ChainID = chainID
+	}
+	if v := os.Getenv("SIWE_MESSAGE_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil {
+			return SIWEConfig{}, fmt.Errorf("invalid SIWE_MESSAGE_TTL: %w", err)
+		}
+		cfg.MessageTTL = ttl
+	}
+	return cfg, nil
+}
+
+// webauthnServiceFromEnv configures the passkey subsystem from
+// WEBAUTHN_RP_DISPLAY_NAME, WEBAUTHN_RP_ID and WEBAUTHN_RP_ORIGIN.
+func webauthnServiceFromEnv() (*authwebauthn.Service, error) {
+	rpDisplayName := os.Getenv("WEBAUTHN_RP_DISPLAY_NAME")
+	if rpDisplayName == "" {
+		rpDisplayName = "MetaMask Login Tutorial"
+	}
+	rpID := os.Getenv("WEBAUTHN_RP_ID")
+	if rpID == "" {
+		rpID = "localhost"
+	}
+	rpOrigin := os.Getenv("WEBAUTHN_RP_ORIGIN")
+	if rpOrigin == "" {
+		rpOrigin = "http://localhost:8001"
+	}
+	return authwebauthn.NewService(rpDisplayName, rpID, []string{rpOrigin}, authwebauthn.NewMemSessionStore())
+}
+
 func run() error {
+	logger := logrus.New()
+
 	// initialization of storage
-	storage := NewMemStorage()
+	storage, err := storageFromEnv()
+	if err != nil {
+		return err
+	}
+
+	jwtSecret := jwtSecretFromEnv()
+
+	siweCfg, err := siweConfigFromEnv()
+	if err != nil {
+		return err
+	}
+
+	webauthnSvc, err := webauthnServiceFromEnv()
+	if err != nil {
+		return err
+	}
 
 	// setup the endpoints
 	r := chi.NewRouter()
 
+	r.Use(chimiddleware.RequestID)
+	r.Use(RequestLogger(logger))
+
 	//  Just allow all for the reference implementation
 	r.Use(cors.AllowAll().Handler)
 
-	r.Post("/register", RegisterHandler(storage))
-	r.Get("/users/{address:^0x[a-fA-F0-9]{40}$}/nonce", UserNonceHandler(storage))
-	r.Post("/signin", SigninHandler())
-	r.Get("/welcome", WelcomeHandler())
+	r.Post("/register", RegisterHandler(storage, logger))
+	r.Get("/users/{address:^0x[a-fA-F0-9]{40}$}/nonce", UserNonceHandler(storage, siweCfg, logger))
+	r.Post("/signin", SigninHandler(storage, jwtSecret, siweCfg, logger))
+	r.Get("/welcome", WelcomeHandler(jwtSecret, logger))
+
+	r.Post("/webauthn/register/begin", WebauthnRegisterBeginHandler(storage, webauthnSvc, logger))
+	r.Post("/webauthn/register/finish", WebauthnRegisterFinishHandler(storage, webauthnSvc, logger))
+	r.Post("/webauthn/login/begin", WebauthnLoginBeginHandler(storage, webauthnSvc, logger))
+	r.Post("/webauthn/login/finish", WebauthnLoginFinishHandler(storage, webauthnSvc, jwtSecret, logger))
 
 	// start the server on port 8001
-	err := http.ListenAndServe("localhost:8001", r)
-	return err
+	logger.WithField("addr", "localhost:8001").Info("starting server")
+	return http.ListenAndServe("localhost:8001", r)
 }
 
 func main() {
 	if err := run(); err != nil {
-		log.Fatalln(err.Error())
+		logrus.WithField("stack", string(debug.Stack())).Fatal(err)
 	}
 }
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+
+	"github.com/gosom/blog-metamask-login-tutorial/backend/siwe"
+)
+
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func testSIWEConfig() SIWEConfig {
+	return SIWEConfig{
+		Domain:     "service.invalid",
+		URI:        "https://service.invalid/login",
+		ChainID:    1,
+		Statement:  "Sign in with your Ethereum account.",
+		MessageTTL: 5 * time.Minute,
+	}
+}
+
+func siweMessageFor(cfg SIWEConfig, address, nonce string) siwe.Message {
+	now := time.Now()
+	expiration := now.Add(cfg.MessageTTL)
+	return siwe.Message{
+		Domain:         cfg.Domain,
+		Address:        address,
+		Statement:      cfg.Statement,
+		URI:            cfg.URI,
+		Version:        "1",
+		ChainID:        cfg.ChainID,
+		Nonce:          nonce,
+		IssuedAt:       now,
+		ExpirationTime: &expiration,
+	}
+}
+
+func newSigner(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+	return key, address
+}
+
+func signMessage(t *testing.T, key *ecdsa.PrivateKey, message string) string {
+	t.Helper()
+	sig, err := crypto.Sign(eip191Hash(message), key)
+	if err != nil {
+		t.Fatalf("sign message: %v", err)
+	}
+	sig[64] += 27 // mimic the recovery id MetaMask returns
+	return "0x" + hex.EncodeToString(sig)
+}
+
+func newUserStorage(t *testing.T, address, nonce string) *MemStorage {
+	t.Helper()
+	storage := NewMemStorage()
+	if err := storage.CreateIfNotExists(User{Address: address, Nonce: nonce}); err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	return storage
+}
+
+func doSignin(t *testing.T, storage *MemStorage, secret []byte, cfg SIWEConfig, message, signature string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, err := json.Marshal(SigninPayload{Message: message, Signature: signature})
+	if err != nil {
+		t.Fatalf("marshal payload: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/signin", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	SigninHandler(storage, secret, cfg, testLogger())(rec, req)
+	return rec
+}
+
+func TestSigninHandler(t *testing.T) {
+	secret := []byte("test-secret")
+	cfg := testSIWEConfig()
+
+	t.Run("valid signature issues a token", func(t *testing.T) {
+		key, address := newSigner(t)
+		storage := newUserStorage(t, strings.ToLower(address), "12345")
+		user, _ := storage.Get(strings.ToLower(address))
+		msg := siweMessageFor(cfg, address, user.Nonce).String()
+		sig := signMessage(t, key, msg)
+
+		rec := doSignin(t, storage, secret, cfg, msg, sig)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+		var resp struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if resp.Token == "" {
+			t.Fatal("expected a non-empty token")
+		}
+	})
+
+	t.Run("bad signature is rejected", func(t *testing.T) {
+		_, address := newSigner(t)
+		other, _ := newSigner(t)
+		storage := newUserStorage(t, strings.ToLower(address), "12345")
+		user, _ := storage.Get(strings.ToLower(address))
+		msg := siweMessageFor(cfg, address, user.Nonce).String()
+		sig := signMessage(t, other, msg)
+
+		rec := doSignin(t, storage, secret, cfg, msg, sig)
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %d", rec.Code)
+		}
+	})
+
+	t.Run("replayed nonce is rejected", func(t *testing.T) {
+		key, address := newSigner(t)
+		storage := newUserStorage(t, strings.ToLower(address), "12345")
+		user, _ := storage.Get(strings.ToLower(address))
+		msg := siweMessageFor(cfg, address, user.Nonce).String()
+		sig := signMessage(t, key, msg)
+
+		first := doSignin(t, storage, secret, cfg, msg, sig)
+		if first.Code != http.StatusOK {
+			t.Fatalf("expected first signin to succeed, got %d", first.Code)
+		}
+		replayed := doSignin(t, storage, secret, cfg, msg, sig)
+		if replayed.Code != http.StatusUnauthorized {
+			t.Fatalf("expected replayed nonce to be rejected, got %d", replayed.Code)
+		}
+	})
+
+	t.Run("domain mismatch is rejected", func(t *testing.T) {
+		key, address := newSigner(t)
+		storage := newUserStorage(t, strings.ToLower(address), "12345")
+		user, _ := storage.Get(strings.ToLower(address))
+		wrongDomainCfg := cfg
+		wrongDomainCfg.Domain = "evil.invalid"
+		msg := siweMessageFor(wrongDomainCfg, address, user.Nonce).String()
+		sig := signMessage(t, key, msg)
+
+		rec := doSignin(t, storage, secret, cfg, msg, sig)
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %d", rec.Code)
+		}
+	})
+}
+
+func TestWelcomeHandler(t *testing.T) {
+	secret := []byte("test-secret")
+
+	t.Run("valid token returns a greeting", func(t *testing.T) {
+		token, err := issueJWT("0xabc0000000000000000000000000000000000a", secret)
+		if err != nil {
+			t.Fatalf("issue token: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/welcome", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		WelcomeHandler(secret, testLogger())(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+	})
+
+	t.Run("expired token is rejected", func(t *testing.T) {
+		now := time.Now()
+		claims := jwt.RegisteredClaims{
+			Subject:   "0xabc0000000000000000000000000000000000a",
+			IssuedAt:  jwt.NewNumericDate(now.Add(-2 * jwtTTL)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(-jwtTTL)),
+		}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+		if err != nil {
+			t.Fatalf("sign expired token: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/welcome", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		WelcomeHandler(secret, testLogger())(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %d", rec.Code)
+		}
+	})
+
+	t.Run("invalid token is rejected", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/welcome", nil)
+		req.Header.Set("Authorization", "Bearer not-a-real-token")
+		rec := httptest.NewRecorder()
+		WelcomeHandler(secret, testLogger())(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %d", rec.Code)
+		}
+	})
+
+	t.Run("missing authorization header is rejected", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/welcome", nil)
+		rec := httptest.NewRecorder()
+		WelcomeHandler(secret, testLogger())(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %d", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/redis/go-redis/v9"
+)
+
+const redisKeyPrefix = "metamask:user:"
+
+func redisUserKey(address string) string {
+	return redisKeyPrefix + address
+}
+
+type RedisStorage struct {
+	client *redis.Client
+}
+
+func NewRedisStorage(redisURL string) (*RedisStorage, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse redis url: %w", err)
+	}
+	client := redis.NewClient(opts)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, fmt.Errorf("ping redis: %w", err)
+	}
+	return &RedisStorage{client: client}, nil
+}
+
+func (s *RedisStorage) CreateIfNotExists(u User) error {
+	ctx := context.Background()
+	payload, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	ok, err := s.client.SetNX(ctx, redisUserKey(u.Address), payload, 0).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrUserExists
+	}
+	return nil
+}
+
+func (s *RedisStorage) Get(address string) (User, error) {
+	ctx := context.Background()
+	var u User
+	payload, err := s.client.Get(ctx, redisUserKey(address)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return u, ErrUserNotExists
+		}
+		return u, err
+	}
+	if err := json.Unmarshal(payload, &u); err != nil {
+		return u, err
+	}
+	return u, nil
+}
+
+// UpdateNonce reads the user, sets the new nonce and writes it back. A single
+// user only ever signs in from one place at a time in this reference
+// implementation, so a plain read-modify-write is enough; a deployment that
+// needs a hard guarantee against concurrent sign-ins for the same address
+// should replace this with a WATCH/MULTI transaction.
+func (s *RedisStorage) UpdateNonce(address, nonce string) error {
+	u, err := s.Get(address)
+	if err != nil {
+		return err
+	}
+	u.Nonce = nonce
+	payload, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(context.Background(), redisUserKey(address), payload, 0).Err()
+}
+
+// UpdateWebauthnData persists a user's webauthn user handle and registered
+// credentials with the same read-modify-write approach as UpdateNonce.
+func (s *RedisStorage) UpdateWebauthnData(address, userHandle string, credentials []webauthn.Credential) error {
+	u, err := s.Get(address)
+	if err != nil {
+		return err
+	}
+	u.WebauthnUserHandle = userHandle
+	u.Credentials = credentials
+	payload, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(context.Background(), redisUserKey(address), payload, 0).Err()
+}
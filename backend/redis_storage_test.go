@@ -0,0 +1,86 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// startRedisContainer spins up a disposable redis used only by this test
+// file; run with `go test -tags=integration ./...` against a Docker daemon.
+func startRedisContainer(t *testing.T) string {
+	t.Helper()
+	ctx := context.Background()
+	req := testcontainers.ContainerRequest{
+		Image:        "redis:7-alpine",
+		ExposedPorts: []string{"6379/tcp"},
+		WaitingFor:   wait.ForListeningPort("6379/tcp"),
+	}
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		t.Fatalf("start redis container: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Logf("terminate redis container: %v", err)
+		}
+	})
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		t.Fatalf("container host: %v", err)
+	}
+	port, err := container.MappedPort(ctx, "6379/tcp")
+	if err != nil {
+		t.Fatalf("container port: %v", err)
+	}
+	return "redis://" + host + ":" + port.Port()
+}
+
+func TestRedisStorage(t *testing.T) {
+	redisURL := startRedisContainer(t)
+	storage, err := NewRedisStorage(redisURL)
+	if err != nil {
+		t.Fatalf("new redis storage: %v", err)
+	}
+
+	user := User{Address: "0xabc0000000000000000000000000000000000a", Nonce: "12345"}
+
+	if err := storage.CreateIfNotExists(user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := storage.CreateIfNotExists(user); err == nil {
+		t.Fatal("expected second create to fail")
+	}
+
+	got, err := storage.Get(user.Address)
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	if !reflect.DeepEqual(got, user) {
+		t.Fatalf("expected %+v, got %+v", user, got)
+	}
+
+	if err := storage.UpdateNonce(user.Address, "67890"); err != nil {
+		t.Fatalf("update nonce: %v", err)
+	}
+	got, err = storage.Get(user.Address)
+	if err != nil {
+		t.Fatalf("get user after update: %v", err)
+	}
+	if got.Nonce != "67890" {
+		t.Fatalf("expected rotated nonce, got %q", got.Nonce)
+	}
+
+	if _, err := storage.Get("0xdead0000000000000000000000000000000000"); err != ErrUserNotExists {
+		t.Fatalf("expected ErrUserNotExists, got %v", err)
+	}
+}
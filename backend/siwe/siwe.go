@@ -0,0 +1,207 @@
+// Package siwe constructs and parses Sign-In with Ethereum messages as
+// defined by EIP-4361 (https://eips.ethereum.org/EIPS/eip-4361).
+package siwe
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const timeLayout = time.RFC3339
+
+var (
+	ErrMalformedMessage = errors.New("siwe: malformed message")
+	ErrDomainMismatch   = errors.New("siwe: domain mismatch")
+	ErrURIMismatch      = errors.New("siwe: uri mismatch")
+	ErrNonceMismatch    = errors.New("siwe: nonce mismatch")
+	ErrIssuedInFuture   = errors.New("siwe: issued-at is in the future")
+	ErrNotYetValid      = errors.New("siwe: not-before has not passed")
+	ErrExpired          = errors.New("siwe: message has expired")
+)
+
+// Message is a parsed/constructed EIP-4361 Sign-In with Ethereum message.
+type Message struct {
+	Domain         string
+	Address        string
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        int
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+// String renders the message in the exact ABNF form a wallet will display
+// and sign, per EIP-4361.
+func (m Message) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s wants you to sign in with your Ethereum account:\n", m.Domain)
+	fmt.Fprintf(&b, "%s\n", m.Address)
+	b.WriteString("\n")
+	if m.Statement != "" {
+		fmt.Fprintf(&b, "%s\n", m.Statement)
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "URI: %s\n", m.URI)
+	fmt.Fprintf(&b, "Version: %s\n", m.Version)
+	fmt.Fprintf(&b, "Chain ID: %d\n", m.ChainID)
+	fmt.Fprintf(&b, "Nonce: %s\n", m.Nonce)
+	fmt.Fprintf(&b, "Issued At: %s", m.IssuedAt.UTC().Format(timeLayout))
+	if m.ExpirationTime != nil {
+		fmt.Fprintf(&b, "\nExpiration Time: %s", m.ExpirationTime.UTC().Format(timeLayout))
+	}
+	if m.NotBefore != nil {
+		fmt.Fprintf(&b, "\nNot Before: %s", m.NotBefore.UTC().Format(timeLayout))
+	}
+	if m.RequestID != "" {
+		fmt.Fprintf(&b, "\nRequest ID: %s", m.RequestID)
+	}
+	if len(m.Resources) > 0 {
+		b.WriteString("\nResources:")
+		for _, res := range m.Resources {
+			fmt.Fprintf(&b, "\n- %s", res)
+		}
+	}
+	return b.String()
+}
+
+// Parse reconstructs a Message from the exact text a client signed. It
+// expects one blank line after the address and, when a statement is present,
+// one blank line after the statement. The fields that follow are looked up by
+// label: their order is not checked, and labels it does not know are ignored.
+func Parse(raw string) (Message, error) {
+	lines := strings.Split(raw, "\n")
+	if len(lines) < 6 {
+		return Message{}, ErrMalformedMessage
+	}
+
+	const greetingSuffix = " wants you to sign in with your Ethereum account:"
+	if !strings.HasSuffix(lines[0], greetingSuffix) {
+		return Message{}, fmt.Errorf("%w: missing greeting", ErrMalformedMessage)
+	}
+	m := Message{
+		Domain:  strings.TrimSuffix(lines[0], greetingSuffix),
+		Address: lines[1],
+	}
+	if lines[2] != "" {
+		return Message{}, fmt.Errorf("%w: expected blank line after address", ErrMalformedMessage)
+	}
+
+	rest := lines[3:]
+	if len(rest) > 0 && rest[0] != "" && !strings.HasPrefix(rest[0], "URI: ") {
+		m.Statement = rest[0]
+		if len(rest) < 2 || rest[1] != "" {
+			return Message{}, fmt.Errorf("%w: expected blank line after statement", ErrMalformedMessage)
+		}
+		rest = rest[2:]
+	}
+
+	fields := map[string]string{}
+	var resources []string
+	inResources := false
+	for _, line := range rest {
+		if inResources {
+			if !strings.HasPrefix(line, "- ") {
+				return Message{}, fmt.Errorf("%w: malformed resource entry %q", ErrMalformedMessage, line)
+			}
+			resources = append(resources, strings.TrimPrefix(line, "- "))
+			continue
+		}
+		if line == "Resources:" {
+			inResources = true
+			continue
+		}
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			return Message{}, fmt.Errorf("%w: malformed field %q", ErrMalformedMessage, line)
+		}
+		fields[key] = value
+	}
+	m.Resources = resources
+
+	var ok bool
+	if m.URI, ok = fields["URI"]; !ok {
+		return Message{}, fmt.Errorf("%w: missing URI", ErrMalformedMessage)
+	}
+	if m.Version, ok = fields["Version"]; !ok {
+		return Message{}, fmt.Errorf("%w: missing Version", ErrMalformedMessage)
+	}
+	chainID, ok := fields["Chain ID"]
+	if !ok {
+		return Message{}, fmt.Errorf("%w: missing Chain ID", ErrMalformedMessage)
+	}
+	chainIDInt, err := strconv.Atoi(chainID)
+	if err != nil {
+		return Message{}, fmt.Errorf("%w: invalid Chain ID", ErrMalformedMessage)
+	}
+	m.ChainID = chainIDInt
+	if m.Nonce, ok = fields["Nonce"]; !ok {
+		return Message{}, fmt.Errorf("%w: missing Nonce", ErrMalformedMessage)
+	}
+	issuedAt, ok := fields["Issued At"]
+	if !ok {
+		return Message{}, fmt.Errorf("%w: missing Issued At", ErrMalformedMessage)
+	}
+	m.IssuedAt, err = time.Parse(timeLayout, issuedAt)
+	if err != nil {
+		return Message{}, fmt.Errorf("%w: invalid Issued At", ErrMalformedMessage)
+	}
+	if v, ok := fields["Expiration Time"]; ok {
+		t, err := time.Parse(timeLayout, v)
+		if err != nil {
+			return Message{}, fmt.Errorf("%w: invalid Expiration Time", ErrMalformedMessage)
+		}
+		m.ExpirationTime = &t
+	}
+	if v, ok := fields["Not Before"]; ok {
+		t, err := time.Parse(timeLayout, v)
+		if err != nil {
+			return Message{}, fmt.Errorf("%w: invalid Not Before", ErrMalformedMessage)
+		}
+		m.NotBefore = &t
+	}
+	m.RequestID = fields["Request ID"]
+
+	return m, nil
+}
+
+// ExpectedParams are the values the server knows independently of the
+// message (its own domain/uri, and the nonce it handed out), used to
+// validate a message presented back to it.
+type ExpectedParams struct {
+	Domain string
+	URI    string
+	Nonce  string
+}
+
+// Validate checks the message against the server's own expectations and the
+// time-bound claims within it. now is passed in explicitly to keep this pure
+// and testable.
+func (m Message) Validate(expected ExpectedParams, now time.Time) error {
+	if m.Domain != expected.Domain {
+		return ErrDomainMismatch
+	}
+	if m.URI != expected.URI {
+		return ErrURIMismatch
+	}
+	if m.Nonce != expected.Nonce {
+		return ErrNonceMismatch
+	}
+	if m.IssuedAt.After(now) {
+		return ErrIssuedInFuture
+	}
+	if m.ExpirationTime != nil && now.After(*m.ExpirationTime) {
+		return ErrExpired
+	}
+	if m.NotBefore != nil && now.Before(*m.NotBefore) {
+		return ErrNotYetValid
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package siwe
+
+import (
+	"testing"
+	"time"
+)
+
+// canonicalMessage is the example message from the EIP-4361 specification.
+const canonicalMessage = `service.invalid wants you to sign in with your Ethereum account:
+0x9D85ca56217D2bb651b00f15e694EB7E713637D4
+
+I accept the ServiceOrg Terms of Service: https://service.invalid/tos
+
+URI: https://service.invalid/login
+Version: 1
+Chain ID: 1
+Nonce: 32891756
+Issued At: 2021-09-30T16:25:24Z
+Resources:
+- ipfs://bafybeiemxf5abjwjbikoz4mc3a3dla6ual3jsgpdr4cjr3oz3evfyavhwq/
+- https://example.com/my-web2-claim.json`
+
+func TestParseAndRenderRoundTrip(t *testing.T) {
+	m, err := Parse(canonicalMessage)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if m.Domain != "service.invalid" {
+		t.Errorf("domain = %q", m.Domain)
+	}
+	if m.Address != "0x9D85ca56217D2bb651b00f15e694EB7E713637D4" {
+		t.Errorf("address = %q", m.Address)
+	}
+	if m.Statement != "I accept the ServiceOrg Terms of Service: https://service.invalid/tos" {
+		t.Errorf("statement = %q", m.Statement)
+	}
+	if m.URI != "https://service.invalid/login" {
+		t.Errorf("uri = %q", m.URI)
+	}
+	if m.ChainID != 1 {
+		t.Errorf("chainID = %d", m.ChainID)
+	}
+	if m.Nonce != "32891756" {
+		t.Errorf("nonce = %q", m.Nonce)
+	}
+	if len(m.Resources) != 2 {
+		t.Fatalf("expected 2 resources, got %d", len(m.Resources))
+	}
+
+	if got := m.String(); got != canonicalMessage {
+		t.Errorf("round-trip mismatch:\n--- got ---\n%s\n--- want ---\n%s", got, canonicalMessage)
+	}
+}
+
+func TestParseWithoutStatement(t *testing.T) {
+	raw := `service.invalid wants you to sign in with your Ethereum account:
+0x9D85ca56217D2bb651b00f15e694EB7E713637D4
+
+URI: https://service.invalid/login
+Version: 1
+Chain ID: 1
+Nonce: abcdef01
+Issued At: 2021-09-30T16:25:24Z`
+
+	m, err := Parse(raw)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if m.Statement != "" {
+		t.Errorf("expected no statement, got %q", m.Statement)
+	}
+	if got := m.String(); got != raw {
+		t.Errorf("round-trip mismatch:\n--- got ---\n%s\n--- want ---\n%s", got, raw)
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	cases := []string{
+		"",
+		"not a siwe message",
+		"example.com wants you to sign in with your Ethereum account:\n0xabc",
+	}
+	for _, raw := range cases {
+		if _, err := Parse(raw); err == nil {
+			t.Errorf("expected an error parsing %q", raw)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	issuedAt := time.Date(2021, 9, 30, 16, 25, 24, 0, time.UTC)
+	expiration := issuedAt.Add(time.Hour)
+	base := Message{
+		Domain:   "service.invalid",
+		URI:      "https://service.invalid/login",
+		Nonce:    "32891756",
+		IssuedAt: issuedAt,
+	}
+	expected := ExpectedParams{Domain: base.Domain, URI: base.URI, Nonce: base.Nonce}
+
+	t.Run("valid message", func(t *testing.T) {
+		if err := base.Validate(expected, issuedAt.Add(time.Minute)); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("domain mismatch", func(t *testing.T) {
+		if err := base.Validate(ExpectedParams{Domain: "other.invalid", URI: base.URI, Nonce: base.Nonce}, issuedAt); err != ErrDomainMismatch {
+			t.Fatalf("expected ErrDomainMismatch, got %v", err)
+		}
+	})
+
+	t.Run("nonce mismatch", func(t *testing.T) {
+		if err := base.Validate(ExpectedParams{Domain: base.Domain, URI: base.URI, Nonce: "wrong"}, issuedAt); err != ErrNonceMismatch {
+			t.Fatalf("expected ErrNonceMismatch, got %v", err)
+		}
+	})
+
+	t.Run("issued in the future", func(t *testing.T) {
+		if err := base.Validate(expected, issuedAt.Add(-time.Minute)); err != ErrIssuedInFuture {
+			t.Fatalf("expected ErrIssuedInFuture, got %v", err)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		withExpiry := base
+		withExpiry.ExpirationTime = &expiration
+		if err := withExpiry.Validate(expected, expiration.Add(time.Minute)); err != ErrExpired {
+			t.Fatalf("expected ErrExpired, got %v", err)
+		}
+	})
+
+	t.Run("not yet valid", func(t *testing.T) {
+		notBefore := issuedAt.Add(time.Hour)
+		withNotBefore := base
+		withNotBefore.NotBefore = &notBefore
+		if err := withNotBefore.Validate(expected, issuedAt.Add(time.Minute)); err != ErrNotYetValid {
+			t.Fatalf("expected ErrNotYetValid, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+var (
+	ErrUserNotExists = errors.New("user does not exist")
+	ErrUserExists    = errors.New("user already exists")
+)
+
+type User struct {
+	Address string
+	Nonce   string
+
+	// WebauthnUserHandle is assigned the first time a user begins passkey
+	// registration and Credentials holds the passkeys registered since; both
+	// are empty for accounts that never used the passkey flow.
+	WebauthnUserHandle string
+	Credentials        []webauthn.Credential
+}
+
+// Storage persists users, their sign-in nonces and their registered
+// passkeys. Implementations must make CreateIfNotExists atomic across
+// concurrent callers (and, for networked backends, across processes) since
+// it is what prevents two registrations racing for the same address.
+type Storage interface {
+	CreateIfNotExists(u User) error
+	Get(address string) (User, error)
+	UpdateNonce(address, nonce string) error
+	UpdateWebauthnData(address, userHandle string, credentials []webauthn.Credential) error
+}
+
+type MemStorage struct {
+	lock  sync.RWMutex
+	users map[string]User
+}
+
+func NewMemStorage() *MemStorage {
+	ans := MemStorage{
+		users: make(map[string]User),
+	}
+	return &ans
+}
+
+func (m *MemStorage) CreateIfNotExists(u User) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, exists := m.users[u.Address]; exists {
+		return ErrUserExists
+	}
+	m.users[u.Address] = u
+	return nil
+}
+
+func (m *MemStorage) Get(address string) (User, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	u, exists := m.users[address]
+	if !exists {
+		return u, ErrUserNotExists
+	}
+	return u, nil
+}
+
+func (m *MemStorage) UpdateNonce(address, nonce string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	u, exists := m.users[address]
+	if !exists {
+		return ErrUserNotExists
+	}
+	u.Nonce = nonce
+	m.users[address] = u
+	return nil
+}
+
+func (m *MemStorage) UpdateWebauthnData(address, userHandle string, credentials []webauthn.Credential) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	u, exists := m.users[address]
+	if !exists {
+		return ErrUserNotExists
+	}
+	u.WebauthnUserHandle = userHandle
+	u.Credentials = credentials
+	m.users[address] = u
+	return nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/sirupsen/logrus"
+
+	authwebauthn "github.com/gosom/blog-metamask-login-tutorial/backend/auth/webauthn"
+)
+
+// webauthnUser adapts our User record to the webauthn.User interface the
+// go-webauthn library expects, so User itself does not implement that
+// interface.
+type webauthnUser struct {
+	u User
+}
+
+func (a webauthnUser) WebAuthnID() []byte                         { return []byte(a.u.WebauthnUserHandle) }
+func (a webauthnUser) WebAuthnName() string                       { return a.u.Address }
+func (a webauthnUser) WebAuthnDisplayName() string                { return a.u.Address }
+func (a webauthnUser) WebAuthnCredentials() []webauthn.Credential { return a.u.Credentials }
+
+type WebauthnBeginPayload struct {
+	Address string `json:"address"`
+}
+
+type WebauthnFinishPayload struct {
+	Address  string          `json:"address"`
+	Response json.RawMessage `json:"response"`
+}
+
+// ensureWebauthnHandle assigns the user a random, opaque webauthn user
+// handle the first time they interact with the passkey flow.
+func ensureWebauthnHandle(storage Storage, user User) (User, error) {
+	if user.WebauthnUserHandle != "" {
+		return user, nil
+	}
+	handle, err := authwebauthn.NewUserHandle()
+	if err != nil {
+		return user, err
+	}
+	if err := storage.UpdateWebauthnData(user.Address, handle, user.Credentials); err != nil {
+		return user, err
+	}
+	user.WebauthnUserHandle = handle
+	return user, nil
+}
+
+// ceremonyRequest turns a raw attestation/assertion response body into the
+// *http.Request shape the go-webauthn library parses its finish calls from.
+func ceremonyRequest(response json.RawMessage) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodPost, "/", bytes.NewReader(response))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+func WebauthnRegisterBeginHandler(storage Storage, svc *authwebauthn.Service, logger *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var p WebauthnBeginPayload
+		if err := bindReqBody(r, &p); err != nil {
+			logRequestError(logger, r, "", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		address := strings.ToLower(p.Address)
+		if !hexRegex.MatchString(address) {
+			logRequestError(logger, r, address, ErrInvalidAddress)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		user, err := storage.Get(address)
+		if err != nil {
+			logRequestError(logger, r, address, err)
+			switch errors.Is(err, ErrUserNotExists) {
+			case true:
+				w.WriteHeader(http.StatusNotFound)
+			default:
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+		user, err = ensureWebauthnHandle(storage, user)
+		if err != nil {
+			logRequestError(logger, r, address, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		options, err := svc.BeginRegistration(webauthnUser{user})
+		if err != nil {
+			logRequestError(logger, r, address, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		renderJson(logger, r, w, http.StatusOK, options)
+	}
+}
+
+func WebauthnRegisterFinishHandler(storage Storage, svc *authwebauthn.Service, logger *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var p WebauthnFinishPayload
+		if err := bindReqBody(r, &p); err != nil {
+			logRequestError(logger, r, "", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		address := strings.ToLower(p.Address)
+		user, err := storage.Get(address)
+		if err != nil {
+			logRequestError(logger, r, address, err)
+			switch errors.Is(err, ErrUserNotExists) {
+			case true:
+				w.WriteHeader(http.StatusNotFound)
+			default:
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+		ceremonyReq, err := ceremonyRequest(p.Response)
+		if err != nil {
+			logRequestError(logger, r, address, err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		cred, err := svc.FinishRegistration(webauthnUser{user}, ceremonyReq)
+		if err != nil {
+			logRequestError(logger, r, address, err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		credentials := append(user.Credentials, *cred)
+		if err := storage.UpdateWebauthnData(address, user.WebauthnUserHandle, credentials); err != nil {
+			logRequestError(logger, r, address, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func WebauthnLoginBeginHandler(storage Storage, svc *authwebauthn.Service, logger *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var p WebauthnBeginPayload
+		if err := bindReqBody(r, &p); err != nil {
+			logRequestError(logger, r, "", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		address := strings.ToLower(p.Address)
+		user, err := storage.Get(address)
+		if err != nil {
+			logRequestError(logger, r, address, err)
+			switch errors.Is(err, ErrUserNotExists) {
+			case true:
+				w.WriteHeader(http.StatusNotFound)
+			default:
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+		if user.WebauthnUserHandle == "" || len(user.Credentials) == 0 {
+			logRequestError(logger, r, address, ErrUserNotExists)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		options, err := svc.BeginLogin(webauthnUser{user})
+		if err != nil {
+			logRequestError(logger, r, address, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		renderJson(logger, r, w, http.StatusOK, options)
+	}
+}
+
+func WebauthnLoginFinishHandler(storage Storage, svc *authwebauthn.Service, jwtSecret []byte, logger *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var p WebauthnFinishPayload
+		if err := bindReqBody(r, &p); err != nil {
+			logRequestError(logger, r, "", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		address := strings.ToLower(p.Address)
+		user, err := storage.Get(address)
+		if err != nil {
+			logRequestError(logger, r, address, err)
+			switch errors.Is(err, ErrUserNotExists) {
+			case true:
+				w.WriteHeader(http.StatusNotFound)
+			default:
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+		ceremonyReq, err := ceremonyRequest(p.Response)
+		if err != nil {
+			logRequestError(logger, r, address, err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		cred, err := svc.FinishLogin(webauthnUser{user}, ceremonyReq)
+		if err != nil {
+			logRequestError(logger, r, address, err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := storage.UpdateWebauthnData(address, user.WebauthnUserHandle, replaceCredential(user.Credentials, *cred)); err != nil {
+			logRequestError(logger, r, address, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		// Same JWT issuance as the MetaMask signin flow, so downstream
+		// handlers like WelcomeHandler don't need to care which
+		// authenticator a session came from.
+		token, err := issueJWT(address, jwtSecret)
+		if err != nil {
+			logRequestError(logger, r, address, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		resp := struct {
+			Token string `json:"token"`
+		}{
+			Token: token,
+		}
+		renderJson(logger, r, w, http.StatusOK, resp)
+	}
+}
+
+// replaceCredential swaps in the updated credential (e.g. its new sign
+// counter) returned by a login ceremony, keeping the rest untouched.
+func replaceCredential(credentials []webauthn.Credential, updated webauthn.Credential) []webauthn.Credential {
+	for i, c := range credentials {
+		if bytes.Equal(c.ID, updated.ID) {
+			credentials[i] = updated
+			return credentials
+		}
+	}
+	return append(credentials, updated)
+}
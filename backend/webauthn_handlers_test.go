@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+func TestEnsureWebauthnHandle(t *testing.T) {
+	address := "0xabc0000000000000000000000000000000000a"
+	storage := newUserStorage(t, address, "12345")
+
+	user, err := storage.Get(address)
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+
+	user, err = ensureWebauthnHandle(storage, user)
+	if err != nil {
+		t.Fatalf("ensure handle: %v", err)
+	}
+	if user.WebauthnUserHandle == "" {
+		t.Fatal("expected a non-empty webauthn user handle")
+	}
+
+	again, err := ensureWebauthnHandle(storage, user)
+	if err != nil {
+		t.Fatalf("ensure handle again: %v", err)
+	}
+	if again.WebauthnUserHandle != user.WebauthnUserHandle {
+		t.Fatal("expected the webauthn user handle to be stable once assigned")
+	}
+}
+
+func TestWebauthnUserAdapter(t *testing.T) {
+	u := User{
+		Address:            "0xabc0000000000000000000000000000000000a",
+		WebauthnUserHandle: "123456789",
+		Credentials:        []webauthn.Credential{{ID: []byte("cred-1")}},
+	}
+	adapter := webauthnUser{u}
+
+	if string(adapter.WebAuthnID()) != u.WebauthnUserHandle {
+		t.Fatalf("expected id %q, got %q", u.WebauthnUserHandle, adapter.WebAuthnID())
+	}
+	if adapter.WebAuthnName() != u.Address {
+		t.Fatalf("expected name %q, got %q", u.Address, adapter.WebAuthnName())
+	}
+	if len(adapter.WebAuthnCredentials()) != 1 {
+		t.Fatalf("expected 1 credential, got %d", len(adapter.WebAuthnCredentials()))
+	}
+}
+
+func TestReplaceCredential(t *testing.T) {
+	existing := []webauthn.Credential{{ID: []byte("cred-1"), Authenticator: webauthn.Authenticator{SignCount: 1}}}
+	updated := webauthn.Credential{ID: []byte("cred-1"), Authenticator: webauthn.Authenticator{SignCount: 2}}
+
+	result := replaceCredential(existing, updated)
+	if len(result) != 1 {
+		t.Fatalf("expected 1 credential, got %d", len(result))
+	}
+	if result[0].Authenticator.SignCount != 2 {
+		t.Fatalf("expected sign count to be updated, got %d", result[0].Authenticator.SignCount)
+	}
+
+	result = replaceCredential(existing, webauthn.Credential{ID: []byte("cred-2")})
+	if len(result) != 2 {
+		t.Fatalf("expected a new credential to be appended, got %d", len(result))
+	}
+}